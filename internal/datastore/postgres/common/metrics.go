@@ -0,0 +1,192 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+var queryExecutionTimeHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "db_client_query_execution_time",
+	Help:      "The amount of time spent executing queries against the underlying database, in seconds.",
+	Buckets:   []float64{.0005, .001, .002, .005, .01, .02, .05, .1, .25, .5, 1, 2.5, 5, 10},
+}, []string{"datastore"})
+
+// poolStatDescs are the *prometheus.Desc values reported by a poolStatCollector.
+// Unlike a package-level Desc with a variable "datastore" label, these are
+// built per-pool with the datastore name baked in as a const label: a Desc's
+// identity to the registry is its fqName plus constLabels, not the label
+// values later supplied to MustNewConstMetric, so two collectors sharing the
+// same variable-label Desc would collide on Register and the second pool's
+// metrics would be silently dropped.
+type poolStatDescs struct {
+	acquireCount         *prometheus.Desc
+	acquiredConns        *prometheus.Desc
+	canceledAcquireCount *prometheus.Desc
+	constructingConns    *prometheus.Desc
+	emptyAcquireCount    *prometheus.Desc
+	idleConns            *prometheus.Desc
+	maxConns             *prometheus.Desc
+	newConnsCount        *prometheus.Desc
+	totalConns           *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+}
+
+func newPoolStatDescs(datastore string) poolStatDescs {
+	constLabels := prometheus.Labels{"datastore": datastore}
+	return poolStatDescs{
+		acquireCount: prometheus.NewDesc(
+			"spicedb_datastore_pgx_acquire_count_total",
+			"The cumulative count of successful acquires from the pool.",
+			nil, constLabels,
+		),
+		acquiredConns: prometheus.NewDesc(
+			"spicedb_datastore_pgx_acquired_conns",
+			"The number of currently acquired connections in the pool.",
+			nil, constLabels,
+		),
+		canceledAcquireCount: prometheus.NewDesc(
+			"spicedb_datastore_pgx_canceled_acquire_count_total",
+			"The cumulative count of acquires from the pool that were canceled by a context.",
+			nil, constLabels,
+		),
+		constructingConns: prometheus.NewDesc(
+			"spicedb_datastore_pgx_constructing_conns",
+			"The number of conns with a connection currently being constructed.",
+			nil, constLabels,
+		),
+		emptyAcquireCount: prometheus.NewDesc(
+			"spicedb_datastore_pgx_empty_acquire_count_total",
+			"The cumulative count of successful acquires from the pool that waited for a resource to be released or constructed because the pool was empty.",
+			nil, constLabels,
+		),
+		idleConns: prometheus.NewDesc(
+			"spicedb_datastore_pgx_idle_conns",
+			"The number of currently idle connections in the pool.",
+			nil, constLabels,
+		),
+		maxConns: prometheus.NewDesc(
+			"spicedb_datastore_pgx_max_conns",
+			"The maximum size of the pool.",
+			nil, constLabels,
+		),
+		newConnsCount: prometheus.NewDesc(
+			"spicedb_datastore_pgx_new_conns_count_total",
+			"The cumulative count of new connections opened.",
+			nil, constLabels,
+		),
+		totalConns: prometheus.NewDesc(
+			"spicedb_datastore_pgx_total_conns",
+			"The total number of resources currently in the pool.",
+			nil, constLabels,
+		),
+		acquireDuration: prometheus.NewDesc(
+			"spicedb_datastore_pgx_acquire_duration_seconds_total",
+			"The total duration of all successful acquires from the pool.",
+			nil, constLabels,
+		),
+	}
+}
+
+// poolStatCollector is a prometheus.Collector that reports gauges and counters
+// derived from a pgxpool.Pool's Stat() snapshot on every scrape.
+type poolStatCollector struct {
+	pool  *pgxpool.Pool
+	descs poolStatDescs
+}
+
+// RegisterPoolMetrics registers a prometheus.Collector that exposes pgxpool
+// connection-pool statistics (as reported by pool.Stat()) for the given pool.
+// labels is typically used to distinguish between multiple pools running in
+// the same process, e.g. {"datastore": "postgres-primary"}; it is safe to
+// call once per pool (primary, replicas, ...), since each pool's metrics are
+// registered under their own datastore-labeled descriptors.
+func RegisterPoolMetrics(pool *pgxpool.Pool, labels prometheus.Labels) error {
+	return registerPoolMetrics(prometheus.DefaultRegisterer, pool, labels)
+}
+
+// registerPoolMetrics does the work of RegisterPoolMetrics against an
+// explicit prometheus.Registerer, so tests can exercise the registration
+// logic (including the queryExecutionTimeHistogram AlreadyRegisteredError
+// swallowing) against an isolated registry instead of the global default.
+func registerPoolMetrics(registerer prometheus.Registerer, pool *pgxpool.Pool, labels prometheus.Labels) error {
+	collector := &poolStatCollector{pool: pool, descs: newPoolStatDescs(labels["datastore"])}
+	if err := registerer.Register(collector); err != nil {
+		return err
+	}
+	if err := registerer.Register(queryExecutionTimeHistogram); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if !errors.As(err, &alreadyRegistered) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *poolStatCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- p.descs.acquireCount
+	descs <- p.descs.acquiredConns
+	descs <- p.descs.canceledAcquireCount
+	descs <- p.descs.constructingConns
+	descs <- p.descs.emptyAcquireCount
+	descs <- p.descs.idleConns
+	descs <- p.descs.maxConns
+	descs <- p.descs.newConnsCount
+	descs <- p.descs.totalConns
+	descs <- p.descs.acquireDuration
+}
+
+func (p *poolStatCollector) Collect(metrics chan<- prometheus.Metric) {
+	stat := p.pool.Stat()
+
+	metrics <- prometheus.MustNewConstMetric(p.descs.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	metrics <- prometheus.MustNewConstMetric(p.descs.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	metrics <- prometheus.MustNewConstMetric(p.descs.canceledAcquireCount, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	metrics <- prometheus.MustNewConstMetric(p.descs.constructingConns, prometheus.GaugeValue, float64(stat.ConstructingConns()))
+	metrics <- prometheus.MustNewConstMetric(p.descs.emptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	metrics <- prometheus.MustNewConstMetric(p.descs.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	metrics <- prometheus.MustNewConstMetric(p.descs.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	metrics <- prometheus.MustNewConstMetric(p.descs.newConnsCount, prometheus.CounterValue, float64(stat.NewConnsCount()))
+	metrics <- prometheus.MustNewConstMetric(p.descs.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	metrics <- prometheus.MustNewConstMetric(p.descs.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+}
+
+// queryExecutionTimeTracer is a pgx.QueryTracer that feeds query execution
+// durations into the db_client_query_execution_time histogram.
+type queryExecutionTimeTracer struct {
+	datastore string
+}
+
+// NewQueryExecutionTimeTracer returns a pgx.QueryTracer that records the
+// duration of every query into the db_client_query_execution_time histogram,
+// labeled by datastore. It is intended to be added to a ComposedTracer.
+func NewQueryExecutionTimeTracer(datastore string) pgx.QueryTracer {
+	return &queryExecutionTimeTracer{datastore: datastore}
+}
+
+type queryStartTimeCtxKey struct{}
+
+func (q *queryExecutionTimeTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartTimeCtxKey{}, time.Now())
+}
+
+func (q *queryExecutionTimeTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	startTime, ok := ctx.Value(queryStartTimeCtxKey{}).(time.Time)
+	if !ok {
+		return
+	}
+
+	if data.Err != nil {
+		log.Debug().Err(data.Err).Str("datastore", q.datastore).Msg("query execution failed")
+	}
+
+	queryExecutionTimeHistogram.WithLabelValues(q.datastore).Observe(time.Since(startTime).Seconds())
+}
@@ -0,0 +1,56 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterPoolMetricsAllowsMultiplePools(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	primary := &poolStatCollector{descs: newPoolStatDescs("postgres-primary")}
+	replica := &poolStatCollector{descs: newPoolStatDescs("postgres-replica")}
+
+	require.NoError(t, registry.Register(primary))
+	require.NoError(t, registry.Register(replica))
+}
+
+func TestRegisterPoolMetricsRejectsDuplicateDatastore(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	first := &poolStatCollector{descs: newPoolStatDescs("postgres-primary")}
+	second := &poolStatCollector{descs: newPoolStatDescs("postgres-primary")}
+
+	require.NoError(t, registry.Register(first))
+	require.Error(t, registry.Register(second))
+}
+
+// TestRegisterPoolMetricsSwallowsSharedHistogramCollision calls the real
+// RegisterPoolMetrics entry point (via the registerer-scoped helper, so the
+// second call doesn't collide with whatever registered
+// queryExecutionTimeHistogram in an earlier test) twice, once per datastore,
+// against an isolated registry: both calls register their own pool
+// collector fine, but the second call's attempt to register the
+// process-wide queryExecutionTimeHistogram a second time must return
+// AlreadyRegisteredError internally and have that swallowed rather than
+// surfaced to the caller.
+func TestRegisterPoolMetricsSwallowsSharedHistogramCollision(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	require.NoError(t, registerPoolMetrics(registry, nil, prometheus.Labels{"datastore": "postgres-primary"}))
+	require.NoError(t, registerPoolMetrics(registry, nil, prometheus.Labels{"datastore": "postgres-replica"}))
+}
+
+// TestRegisterPoolMetricsRejectsDuplicatePoolRegistration proves
+// registerPoolMetrics doesn't swallow every error, only the expected
+// AlreadyRegisteredError for the shared histogram: registering the same
+// datastore label twice collides on the pool collector's Desc and must
+// still fail.
+func TestRegisterPoolMetricsRejectsDuplicatePoolRegistration(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	require.NoError(t, registerPoolMetrics(registry, nil, prometheus.Labels{"datastore": "postgres-primary"}))
+	require.Error(t, registerPoolMetrics(registry, nil, prometheus.Labels{"datastore": "postgres-primary"}))
+}
@@ -109,9 +109,35 @@ func ConfigurePGXLogger(connConfig *pgx.ConnConfig) {
 	addTracer(connConfig, &tracelog.TraceLog{Logger: levelMappingFn(l), LogLevel: tracelog.LogLevelInfo})
 }
 
-// ConfigureOTELTracer adds OTEL tracing to a pgx.ConnConfig
+// ConfigureOTELTracer adds OTEL tracing to a pgx.ConnConfig. The tracer is
+// wrapped so that a query queryOptionsTracer has filtered out via
+// TracingOptions never gets a span, without affecting any other tracer in
+// the chain (the zerolog logger, the execution-time histogram), which must
+// keep observing every query regardless of the OTEL sampling decision.
 func ConfigureOTELTracer(connConfig *pgx.ConnConfig) {
-	addTracer(connConfig, otelpgx.NewTracer(otelpgx.WithTrimSQLInSpanName()))
+	addTracer(connConfig, &suppressibleTracer{tracer: otelpgx.NewTracer(otelpgx.WithTrimSQLInSpanName())})
+}
+
+// suppressibleTracer wraps a pgx.QueryTracer (specifically the OTEL one) so
+// it can be skipped for a query that queryOptionsTracer has marked as
+// traceSuppressed, without touching any other tracer added to the same
+// ComposedTracer.
+type suppressibleTracer struct {
+	tracer pgx.QueryTracer
+}
+
+func (s *suppressibleTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if traceSuppressed(ctx) {
+		return ctx
+	}
+	return s.tracer.TraceQueryStart(ctx, conn, data)
+}
+
+func (s *suppressibleTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	if traceSuppressed(ctx) {
+		return
+	}
+	s.tracer.TraceQueryEnd(ctx, conn, data)
 }
 
 func addTracer(connConfig *pgx.ConnConfig, tracer pgx.QueryTracer) {
@@ -154,6 +180,86 @@ func (m *ComposedTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data
 	}
 }
 
+func (m *ComposedTracer) TraceConnectStart(ctx context.Context, data pgx.TraceConnectStartData) context.Context {
+	for _, t := range m.Tracers {
+		if tracer, ok := t.(pgx.ConnectTracer); ok {
+			ctx = tracer.TraceConnectStart(ctx, data)
+		}
+	}
+
+	return ctx
+}
+
+func (m *ComposedTracer) TraceConnectEnd(ctx context.Context, data pgx.TraceConnectEndData) {
+	for _, t := range m.Tracers {
+		if tracer, ok := t.(pgx.ConnectTracer); ok {
+			tracer.TraceConnectEnd(ctx, data)
+		}
+	}
+}
+
+func (m *ComposedTracer) TracePrepareStart(ctx context.Context, conn *pgx.Conn, data pgx.TracePrepareStartData) context.Context {
+	for _, t := range m.Tracers {
+		if tracer, ok := t.(pgx.PrepareTracer); ok {
+			ctx = tracer.TracePrepareStart(ctx, conn, data)
+		}
+	}
+
+	return ctx
+}
+
+func (m *ComposedTracer) TracePrepareEnd(ctx context.Context, conn *pgx.Conn, data pgx.TracePrepareEndData) {
+	for _, t := range m.Tracers {
+		if tracer, ok := t.(pgx.PrepareTracer); ok {
+			tracer.TracePrepareEnd(ctx, conn, data)
+		}
+	}
+}
+
+func (m *ComposedTracer) TraceBatchStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	for _, t := range m.Tracers {
+		if tracer, ok := t.(pgx.BatchTracer); ok {
+			ctx = tracer.TraceBatchStart(ctx, conn, data)
+		}
+	}
+
+	return ctx
+}
+
+func (m *ComposedTracer) TraceBatchQuery(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchQueryData) {
+	for _, t := range m.Tracers {
+		if tracer, ok := t.(pgx.BatchTracer); ok {
+			tracer.TraceBatchQuery(ctx, conn, data)
+		}
+	}
+}
+
+func (m *ComposedTracer) TraceBatchEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchEndData) {
+	for _, t := range m.Tracers {
+		if tracer, ok := t.(pgx.BatchTracer); ok {
+			tracer.TraceBatchEnd(ctx, conn, data)
+		}
+	}
+}
+
+func (m *ComposedTracer) TraceCopyFromStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	for _, t := range m.Tracers {
+		if tracer, ok := t.(pgx.CopyFromTracer); ok {
+			ctx = tracer.TraceCopyFromStart(ctx, conn, data)
+		}
+	}
+
+	return ctx
+}
+
+func (m *ComposedTracer) TraceCopyFromEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	for _, t := range m.Tracers {
+		if tracer, ok := t.(pgx.CopyFromTracer); ok {
+			tracer.TraceCopyFromEnd(ctx, conn, data)
+		}
+	}
+}
+
 // DBFuncQuerier is satisfied by RetryPool and QuerierFuncs (which can wrap a pgxpool or transaction)
 type DBFuncQuerier interface {
 	ExecFunc(ctx context.Context, tagFunc func(ctx context.Context, tag pgconn.CommandTag, err error) error, sql string, arguments ...any) error
@@ -169,6 +275,34 @@ type PoolOptions struct {
 	ConnHealthCheckInterval *time.Duration
 	MinOpenConns            *int
 	MaxOpenConns            *int
+
+	// DatastoreName labels the metrics and traces emitted for the pool
+	// configured by these options (e.g. "postgres", "cockroachdb-replica"),
+	// so that multiple pools in the same process remain distinguishable.
+	DatastoreName string
+
+	// QueryExecMode controls pgx's prepared-statement behavior, via
+	// pgx.QueryExecMode's textual names: "cache_statement", "cache_describe",
+	// "describe_exec", "exec", or "simple_protocol". It is left unset (pgx's
+	// default of "cache_statement") unless overridden, which is unsafe
+	// behind connection poolers such as pgbouncer in transaction mode.
+	QueryExecMode string
+
+	// Tracing controls the sampling rate, statement filtering, and payload
+	// captured by per-query OTEL spans. The zero value traces every query
+	// with no argument or rows-affected capture, matching the previous,
+	// unconditional otelpgx-only behavior.
+	Tracing TracingOptions
+}
+
+// queryExecModesByName maps the CLI/config names for pgx.QueryExecMode to
+// their pgx values.
+var queryExecModesByName = map[string]pgx.QueryExecMode{
+	"cache_statement": pgx.QueryExecModeCacheStatement,
+	"cache_describe":  pgx.QueryExecModeCacheDescribe,
+	"describe_exec":   pgx.QueryExecModeDescribeExec,
+	"exec":            pgx.QueryExecModeExec,
+	"simple_protocol": pgx.QueryExecModeSimpleProtocol,
 }
 
 // ConfigurePgx applies PoolOptions to a pgx connection pool confiugration.
@@ -204,8 +338,25 @@ func (opts PoolOptions) ConfigurePgx(pgxConfig *pgxpool.Config) {
 		pgxConfig.MaxConnLifetimeJitter = time.Duration(0.2 * float64(*opts.ConnMaxLifetime))
 	}
 
+	if opts.QueryExecMode != "" {
+		mode, ok := queryExecModesByName[opts.QueryExecMode]
+		if !ok {
+			log.Warn().Str("query-exec-mode", opts.QueryExecMode).Msg("unknown query exec mode configured; leaving pgx default in place")
+		} else {
+			pgxConfig.ConnConfig.DefaultQueryExecMode = mode
+		}
+	}
+
+	// NewQueryOptionsTracer must be added before ConfigureOTELTracer: it
+	// marks in ctx whether this query should get a span at all, and that
+	// marker has to already be there by the time the OTEL tracer's (wrapped
+	// in suppressibleTracer) TraceQueryStart checks it. Unlike OTEL, the
+	// logger and the execution-time histogram are not gated by this
+	// decision and keep observing every query.
+	addTracer(pgxConfig.ConnConfig, NewQueryOptionsTracer(opts.Tracing))
 	ConfigurePGXLogger(pgxConfig.ConnConfig)
 	ConfigureOTELTracer(pgxConfig.ConnConfig)
+	addTracer(pgxConfig.ConnConfig, NewQueryExecutionTimeTracer(opts.DatastoreName))
 }
 
 type QuerierFuncs struct {
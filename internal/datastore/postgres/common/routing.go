@@ -0,0 +1,207 @@
+package common
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	log "github.com/authzed/spicedb/internal/logging"
+)
+
+// forcePrimaryCtxKey is the context key used to request that a query be
+// routed to the primary pool even when a RoutingQuerier would otherwise send
+// it to a replica.
+type forcePrimaryCtxKey struct{}
+
+// WithForcePrimaryRead marks the context so that a RoutingQuerier sends any
+// read issued with it to the primary pool rather than a replica. Callers
+// should use this when replica staleness (e.g. CockroachDB's
+// AS OF SYSTEM TIME follower reads) is not acceptable for the query being
+// issued.
+func WithForcePrimaryRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forcePrimaryCtxKey{}, true)
+}
+
+func forcePrimaryRead(ctx context.Context) bool {
+	forced, _ := ctx.Value(forcePrimaryCtxKey{}).(bool)
+	return forced
+}
+
+// replicaQuerier pairs a DBFuncQuerier over a replica pool with a health flag
+// that is kept up to date by a background prober, so an unreachable replica
+// can be skipped without waiting for a query to fail against it.
+//
+// pgxpool's own HealthCheckPeriod only governs recycling of individual idle
+// connections within a pool; it has no notion of "this whole replica is
+// unreachable, stop routing to it", which is the decision RoutingQuerier
+// needs to make, so a dedicated prober is kept here rather than relying on
+// it directly.
+type replicaQuerier struct {
+	querier DBFuncQuerier
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+func (r *replicaQuerier) probe(ctx context.Context) {
+	r.healthy.Store(r.pool.Ping(ctx) == nil)
+}
+
+// RoutingQuerier is a DBFuncQuerier that sends writes (ExecFunc) and
+// revision-sensitive reads (QueryRowFunc) to the primary pool, while
+// distributing read-only tuple queries (QueryFunc) across a set of replica
+// pools, falling back to the primary when no replica is healthy or the
+// caller has requested WithForcePrimaryRead.
+type RoutingQuerier struct {
+	primary  DBFuncQuerier
+	replicas []*replicaQuerier
+	next     atomic.Uint64
+	cancel   context.CancelFunc
+}
+
+// NewRoutingQuerier builds a RoutingQuerier that always executes writes and
+// single-row reads against primary, and round-robins QueryFunc calls across
+// replicas (each replica's health is checked with a pool.Ping every
+// healthCheckPeriod). If no replicas are provided, all queries are sent to
+// primary.
+func NewRoutingQuerier(primary DBFuncQuerier, healthCheckPeriod time.Duration, replicaPools ...*pgxpool.Pool) *RoutingQuerier {
+	rq := &RoutingQuerier{primary: primary}
+	for _, pool := range replicaPools {
+		rq.replicas = append(rq.replicas, &replicaQuerier{
+			querier: QuerierFuncsFor(pool),
+			pool:    pool,
+		})
+	}
+
+	if len(rq.replicas) == 0 {
+		return rq
+	}
+
+	if healthCheckPeriod <= 0 {
+		healthCheckPeriod = defaultReplicaHealthCheckPeriod
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rq.cancel = cancel
+
+	// Probe synchronously before returning, so a replica that's already down
+	// is never selected for up to healthCheckPeriod before the first
+	// background probe has a chance to run.
+	initialProbeCtx, cancelInitialProbe := context.WithTimeout(ctx, initialProbeTimeout(healthCheckPeriod))
+	for _, replica := range rq.replicas {
+		replica.probe(initialProbeCtx)
+		go replica.probeLoop(ctx, healthCheckPeriod)
+	}
+	cancelInitialProbe()
+
+	return rq
+}
+
+const defaultReplicaHealthCheckPeriod = 15 * time.Second
+
+// initialProbeTimeout bounds the synchronous startup probe so that a
+// replica which is hanging (rather than cleanly refusing connections) can't
+// block NewRoutingQuerier indefinitely.
+func initialProbeTimeout(healthCheckPeriod time.Duration) time.Duration {
+	const maxInitialProbeTimeout = 5 * time.Second
+	if healthCheckPeriod < maxInitialProbeTimeout {
+		return healthCheckPeriod
+	}
+	return maxInitialProbeTimeout
+}
+
+func (r *replicaQuerier) probeLoop(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.probe(ctx)
+		}
+	}
+}
+
+// Stop terminates the background health-check probes for any replicas. It is
+// a no-op if the RoutingQuerier was built without replicas.
+func (r *RoutingQuerier) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+}
+
+// healthyReplica returns a replica to route a read to, or nil if none are
+// currently healthy.
+func (r *RoutingQuerier) healthyReplica() *replicaQuerier {
+	if len(r.replicas) == 0 {
+		return nil
+	}
+
+	n := uint64(len(r.replicas))
+	start := r.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		replica := r.replicas[(start+i)%n]
+		if replica.healthy.Load() {
+			return replica
+		}
+	}
+
+	return nil
+}
+
+func (r *RoutingQuerier) ExecFunc(ctx context.Context, tagFunc func(ctx context.Context, tag pgconn.CommandTag, err error) error, sql string, arguments ...any) error {
+	return r.primary.ExecFunc(ctx, tagFunc, sql, arguments...)
+}
+
+// deliveryTrackingRows wraps a pgx.Rows to record whether any row was ever
+// handed back by Next(), so a caller can tell a clean pre-scan failure
+// (safe to retry) apart from a failure mid-scan (not safe to retry, since
+// rowsFunc is side-effecting and has already observed rows).
+type deliveryTrackingRows struct {
+	pgx.Rows
+	delivered *bool
+}
+
+func (d *deliveryTrackingRows) Next() bool {
+	ok := d.Rows.Next()
+	if ok {
+		*d.delivered = true
+	}
+	return ok
+}
+
+func (r *RoutingQuerier) QueryFunc(ctx context.Context, rowsFunc func(ctx context.Context, rows pgx.Rows) error, sql string, optionsAndArgs ...any) error {
+	if !forcePrimaryRead(ctx) {
+		if replica := r.healthyReplica(); replica != nil {
+			var delivered bool
+			trackedRowsFunc := func(ctx context.Context, rows pgx.Rows) error {
+				return rowsFunc(ctx, &deliveryTrackingRows{Rows: rows, delivered: &delivered})
+			}
+
+			err := replica.querier.QueryFunc(ctx, trackedRowsFunc, sql, optionsAndArgs...)
+			if err == nil {
+				return nil
+			}
+
+			if delivered {
+				// rowsFunc has already folded one or more replica rows into its
+				// caller-owned accumulator/visitor; retrying against primary
+				// would duplicate them, so surface the error instead.
+				return err
+			}
+
+			log.Warn().Err(err).Msg("replica read failed before any row was delivered, falling back to primary")
+		}
+	}
+
+	return r.primary.QueryFunc(ctx, rowsFunc, sql, optionsAndArgs...)
+}
+
+func (r *RoutingQuerier) QueryRowFunc(ctx context.Context, rowFunc func(ctx context.Context, row pgx.Row) error, sql string, optionsAndArgs ...any) error {
+	return r.primary.QueryRowFunc(ctx, rowFunc, sql, optionsAndArgs...)
+}
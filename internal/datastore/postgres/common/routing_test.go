@@ -0,0 +1,166 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+// stubRows is a minimal pgx.Rows whose Next() yields a fixed number of rows
+// before returning false, enough to exercise deliveryTrackingRows without a
+// live connection.
+type stubRows struct {
+	remaining int
+}
+
+func (s *stubRows) Close()                                       {}
+func (s *stubRows) Err() error                                   { return nil }
+func (s *stubRows) CommandTag() pgconn.CommandTag                 { return pgconn.CommandTag{} }
+func (s *stubRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (s *stubRows) Scan(dest ...any) error                        { return nil }
+func (s *stubRows) Values() ([]any, error)                        { return nil, nil }
+func (s *stubRows) RawValues() [][]byte                           { return nil }
+func (s *stubRows) Conn() *pgx.Conn                                { return nil }
+
+func (s *stubRows) Next() bool {
+	if s.remaining <= 0 {
+		return false
+	}
+	s.remaining--
+	return true
+}
+
+// fakeDBFuncQuerier is a DBFuncQuerier double that runs rowsFunc against a
+// fixed number of stub rows and then returns a fixed error, standing in for
+// a replica that fails mid-scan (rowCount > 0) or before ever scanning a row
+// (rowCount == 0).
+type fakeDBFuncQuerier struct {
+	rowCount int
+	queryErr error
+	called   int
+}
+
+func (f *fakeDBFuncQuerier) ExecFunc(ctx context.Context, tagFunc func(ctx context.Context, tag pgconn.CommandTag, err error) error, sql string, arguments ...any) error {
+	return tagFunc(ctx, pgconn.CommandTag{}, nil)
+}
+
+func (f *fakeDBFuncQuerier) QueryFunc(ctx context.Context, rowsFunc func(ctx context.Context, rows pgx.Rows) error, sql string, optionsAndArgs ...any) error {
+	f.called++
+	rows := &stubRows{remaining: f.rowCount}
+	_ = rowsFunc(ctx, rows)
+	return f.queryErr
+}
+
+func (f *fakeDBFuncQuerier) QueryRowFunc(ctx context.Context, rowFunc func(ctx context.Context, row pgx.Row) error, sql string, optionsAndArgs ...any) error {
+	return nil
+}
+
+func consumeAllRows(ctx context.Context, rows pgx.Rows) error {
+	for rows.Next() {
+	}
+	return nil
+}
+
+func newTestRoutingQuerier(primary, replica *fakeDBFuncQuerier) *RoutingQuerier {
+	return &RoutingQuerier{
+		primary: primary,
+		replicas: []*replicaQuerier{
+			{querier: replica},
+		},
+	}
+}
+
+func markHealthy(rq *RoutingQuerier) {
+	for _, replica := range rq.replicas {
+		replica.healthy.Store(true)
+	}
+}
+
+func TestRoutingQuerierQueryFuncPrefersHealthyReplica(t *testing.T) {
+	primary := &fakeDBFuncQuerier{}
+	replica := &fakeDBFuncQuerier{rowCount: 3}
+	rq := newTestRoutingQuerier(primary, replica)
+	markHealthy(rq)
+
+	err := rq.QueryFunc(context.Background(), consumeAllRows, "SELECT 1")
+	require.NoError(t, err)
+	require.Equal(t, 1, replica.called)
+	require.Equal(t, 0, primary.called)
+}
+
+func TestRoutingQuerierQueryFuncForcePrimaryReadSkipsReplica(t *testing.T) {
+	primary := &fakeDBFuncQuerier{}
+	replica := &fakeDBFuncQuerier{rowCount: 3}
+	rq := newTestRoutingQuerier(primary, replica)
+	markHealthy(rq)
+
+	ctx := WithForcePrimaryRead(context.Background())
+	err := rq.QueryFunc(ctx, consumeAllRows, "SELECT 1")
+	require.NoError(t, err)
+	require.Equal(t, 0, replica.called)
+	require.Equal(t, 1, primary.called)
+}
+
+func TestRoutingQuerierQueryFuncFallsBackBeforeAnyRowDelivered(t *testing.T) {
+	primary := &fakeDBFuncQuerier{}
+	replicaErr := errors.New("replica unreachable")
+	replica := &fakeDBFuncQuerier{rowCount: 0, queryErr: replicaErr}
+	rq := newTestRoutingQuerier(primary, replica)
+	markHealthy(rq)
+
+	err := rq.QueryFunc(context.Background(), consumeAllRows, "SELECT 1")
+	require.NoError(t, err)
+	require.Equal(t, 1, replica.called)
+	require.Equal(t, 1, primary.called)
+}
+
+func TestRoutingQuerierQueryFuncDoesNotRetryAfterPartialDelivery(t *testing.T) {
+	primary := &fakeDBFuncQuerier{}
+	replicaErr := errors.New("connection reset mid-scan")
+	replica := &fakeDBFuncQuerier{rowCount: 2, queryErr: replicaErr}
+	rq := newTestRoutingQuerier(primary, replica)
+	markHealthy(rq)
+
+	err := rq.QueryFunc(context.Background(), consumeAllRows, "SELECT 1")
+	require.ErrorIs(t, err, replicaErr)
+	require.Equal(t, 1, replica.called)
+	require.Equal(t, 0, primary.called, "must not re-run the query against primary once rows were already delivered")
+}
+
+func TestRoutingQuerierHealthyReplicaRoundRobins(t *testing.T) {
+	rq := &RoutingQuerier{
+		replicas: []*replicaQuerier{
+			{querier: &fakeDBFuncQuerier{}},
+			{querier: &fakeDBFuncQuerier{}},
+		},
+	}
+	markHealthy(rq)
+
+	first := rq.healthyReplica()
+	second := rq.healthyReplica()
+	require.NotSame(t, first, second)
+
+	third := rq.healthyReplica()
+	require.Same(t, first, third)
+}
+
+func TestRoutingQuerierHealthyReplicaSkipsUnhealthy(t *testing.T) {
+	unhealthy := &replicaQuerier{querier: &fakeDBFuncQuerier{}}
+	healthy := &replicaQuerier{querier: &fakeDBFuncQuerier{}}
+	healthy.healthy.Store(true)
+
+	rq := &RoutingQuerier{replicas: []*replicaQuerier{unhealthy, healthy}}
+
+	for i := 0; i < 4; i++ {
+		require.Same(t, healthy, rq.healthyReplica())
+	}
+}
+
+func TestRoutingQuerierHealthyReplicaNoneHealthyReturnsNil(t *testing.T) {
+	rq := &RoutingQuerier{replicas: []*replicaQuerier{{querier: &fakeDBFuncQuerier{}}}}
+	require.Nil(t, rq.healthyReplica())
+}
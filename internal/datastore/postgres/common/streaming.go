@@ -0,0 +1,87 @@
+package common
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	corev1 "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+// tupleCountEventInterval controls how often a "Tuples loaded" trace event is
+// emitted while streaming, so long-running scans remain observable without a
+// span attribute update per row.
+const tupleCountEventInterval = 1000
+
+// StreamingExecuteQueryFunc is the streaming sibling of
+// common.ExecuteQueryFunc: rather than buffering every matching tuple into a
+// slice, it folds over them one at a time via visitor, stopping early if
+// visitor returns an error.
+type StreamingExecuteQueryFunc func(ctx context.Context, sql string, args []any, visitor func(*corev1.RelationTuple) error) error
+
+// NewPGXStreamingExecutor creates a StreamingExecuteQueryFunc that uses the
+// pgx library to make the specified queries, scanning and yielding tuples
+// row-by-row instead of accumulating them, to bound memory use on large
+// lookup/expand scans.
+func NewPGXStreamingExecutor(querier DBFuncQuerier) StreamingExecuteQueryFunc {
+	return func(ctx context.Context, sqlStatement string, args []any, visitor func(*corev1.RelationTuple) error) error {
+		span := trace.SpanFromContext(ctx)
+		return queryTuplesStreaming(ctx, sqlStatement, args, span, querier, visitor)
+	}
+}
+
+// queryTuplesStreaming queries tuples for the given query and transaction,
+// invoking visitor for each row scanned without buffering the result set.
+func queryTuplesStreaming(ctx context.Context, sqlStatement string, args []any, span trace.Span, tx DBFuncQuerier, visitor func(*corev1.RelationTuple) error) error {
+	return tx.QueryFunc(ctx, func(ctx context.Context, rows pgx.Rows) error {
+		span.AddEvent("Query issued to database")
+
+		tupleCount := 0
+		for rows.Next() {
+			nextTuple := &corev1.RelationTuple{
+				ResourceAndRelation: &corev1.ObjectAndRelation{},
+				Subject:             &corev1.ObjectAndRelation{},
+			}
+			var caveatName sql.NullString
+			var caveatCtx map[string]any
+			err := rows.Scan(
+				&nextTuple.ResourceAndRelation.Namespace,
+				&nextTuple.ResourceAndRelation.ObjectId,
+				&nextTuple.ResourceAndRelation.Relation,
+				&nextTuple.Subject.Namespace,
+				&nextTuple.Subject.ObjectId,
+				&nextTuple.Subject.Relation,
+				&caveatName,
+				&caveatCtx,
+			)
+			if err != nil {
+				return fmt.Errorf(errUnableToQueryTuples, fmt.Errorf("scan err: %w", err))
+			}
+
+			nextTuple.Caveat, err = common.ContextualizedCaveatFrom(caveatName.String, caveatCtx)
+			if err != nil {
+				return fmt.Errorf(errUnableToQueryTuples, fmt.Errorf("unable to fetch caveat context: %w", err))
+			}
+
+			if err := visitor(nextTuple); err != nil {
+				return err
+			}
+
+			tupleCount++
+			if tupleCount%tupleCountEventInterval == 0 {
+				span.AddEvent("Tuples loaded", trace.WithAttributes(attribute.Int("tupleCount", tupleCount)))
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf(errUnableToQueryTuples, fmt.Errorf("rows err: %w", err))
+		}
+
+		span.AddEvent("Tuples loaded", trace.WithAttributes(attribute.Int("tupleCount", tupleCount)))
+		return nil
+	}, sqlStatement, args...)
+}
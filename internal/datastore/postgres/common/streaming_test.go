@@ -0,0 +1,62 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+
+	corev1 "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+// simpleDBFuncQuerier mirrors the real QuerierFuncs.QueryFunc's contract of
+// returning whatever rowsFunc returns, unlike fakeDBFuncQuerier (used by the
+// RoutingQuerier tests), which models a fixed post-scan query error.
+type simpleDBFuncQuerier struct {
+	rowCount int
+}
+
+func (s *simpleDBFuncQuerier) ExecFunc(ctx context.Context, tagFunc func(ctx context.Context, tag pgconn.CommandTag, err error) error, sql string, arguments ...any) error {
+	return tagFunc(ctx, pgconn.CommandTag{}, nil)
+}
+
+func (s *simpleDBFuncQuerier) QueryFunc(ctx context.Context, rowsFunc func(ctx context.Context, rows pgx.Rows) error, sql string, optionsAndArgs ...any) error {
+	return rowsFunc(ctx, &stubRows{remaining: s.rowCount})
+}
+
+func (s *simpleDBFuncQuerier) QueryRowFunc(ctx context.Context, rowFunc func(ctx context.Context, row pgx.Row) error, sql string, optionsAndArgs ...any) error {
+	return nil
+}
+
+func TestPGXStreamingExecutorYieldsRowByRow(t *testing.T) {
+	exec := NewPGXStreamingExecutor(&simpleDBFuncQuerier{rowCount: 5})
+
+	var visited int
+	err := exec(context.Background(), "SELECT 1", nil, func(*corev1.RelationTuple) error {
+		visited++
+		return nil
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, 5, visited)
+}
+
+func TestPGXStreamingExecutorStopsOnVisitorError(t *testing.T) {
+	exec := NewPGXStreamingExecutor(&simpleDBFuncQuerier{rowCount: 5})
+
+	stopErr := errors.New("stop early")
+	var visited int
+	err := exec(context.Background(), "SELECT 1", nil, func(*corev1.RelationTuple) error {
+		visited++
+		if visited == 2 {
+			return stopErr
+		}
+		return nil
+	})
+
+	require.ErrorIs(t, err, stopErr)
+	require.Equal(t, 2, visited, "visitor must not be called again once it has returned an error")
+}
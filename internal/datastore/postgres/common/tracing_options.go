@@ -0,0 +1,176 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingOptions configures how much detail per-query OTEL spans carry, so
+// that operators of high-QPS SpiceDB deployments (where every SELECT against
+// relation_tuple creates a span) can control sampling and payload size.
+type TracingOptions struct {
+	// QuerySamplingRatio is the fraction, in [0, 1], of queries that get a
+	// span at all. A zero value samples every query (the previous,
+	// unconditional behavior).
+	QuerySamplingRatio float64
+
+	// AllowedStatementPrefixes, if non-empty, restricts query span creation
+	// to statements starting with one of these prefixes (a cheap stand-in
+	// for a full fingerprint match). Evaluated before QuerySamplingRatio.
+	AllowedStatementPrefixes []string
+
+	// DeniedStatementPrefixes suppresses span creation for statements
+	// starting with any of these prefixes, taking precedence over
+	// AllowedStatementPrefixes.
+	DeniedStatementPrefixes []string
+
+	// CaptureQueryArgs, when true, attaches query arguments to the span as
+	// attributes. Caveat context values (maps) are always redacted rather
+	// than captured, since they may carry sensitive caller-supplied data.
+	CaptureQueryArgs bool
+
+	// CaptureRowsAffected, when true, records CommandTag().RowsAffected()
+	// on the span at TraceQueryEnd.
+	CaptureRowsAffected bool
+}
+
+// disableQueryTracingCtxKey lets a caller opt a context out of per-query
+// tracing entirely, regardless of TracingOptions, e.g. for a background
+// schema-watching goroutine that polls constantly and would otherwise
+// dominate a trace backend with low-value spans.
+type disableQueryTracingCtxKey struct{}
+
+// WithoutQueryTracing returns a context that skips span creation in the
+// tracer added by TracingOptions, no matter what it's configured to sample.
+func WithoutQueryTracing(ctx context.Context) context.Context {
+	return context.WithValue(ctx, disableQueryTracingCtxKey{}, true)
+}
+
+func queryTracingDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(disableQueryTracingCtxKey{}).(bool)
+	return disabled
+}
+
+// queryOptionsTracer is a pgx.QueryTracer that decides whether a query gets
+// an OTEL span and annotates it, rather than creating an independent span of
+// its own. It marks the decision in ctx via traceSuppressedCtxKey, which the
+// OTEL tracer (wrapped in a suppressibleTracer by ConfigureOTELTracer) reads
+// to skip span creation entirely. Unlike suppression, that marker does not
+// stop any other tracer in the ComposedTracer chain — the zerolog logger and
+// the execution-time histogram must keep observing every query regardless
+// of the OTEL sampling decision. It must be registered before
+// ConfigureOTELTracer so the marker is already in ctx by the time the OTEL
+// tracer's TraceQueryStart checks it, and before the span is ended so its
+// own TraceQueryEnd can still attach attributes.
+type queryOptionsTracer struct {
+	opts TracingOptions
+}
+
+// NewQueryOptionsTracer returns a pgx.QueryTracer implementing the sampling,
+// statement filtering, argument capture, and rows-affected behavior
+// described by opts.
+func NewQueryOptionsTracer(opts TracingOptions) pgx.QueryTracer {
+	return &queryOptionsTracer{opts: opts}
+}
+
+// traceSuppressedCtxKey marks a query's context as having been dropped by
+// queryOptionsTracer's sampling/filtering decision. Only suppressibleTracer
+// (which wraps the OTEL tracer) checks this; it deliberately does not affect
+// any other tracer, so things like query-execution-time metrics and error
+// logging keep working for queries that are sampled out of tracing.
+type traceSuppressedCtxKey struct{}
+
+func traceSuppressed(ctx context.Context) bool {
+	suppressed, _ := ctx.Value(traceSuppressedCtxKey{}).(bool)
+	return suppressed
+}
+
+// queryArgsCtxKey carries the query's arguments from TraceQueryStart to
+// TraceQueryEnd, where they're attached to the span: the span that otelpgx
+// creates isn't available yet when this tracer's TraceQueryStart runs (it
+// runs first in the chain, precisely so it can suppress otelpgx's), but by
+// TraceQueryEnd time every tracer's TraceQueryStart has already run and the
+// span is in ctx.
+type queryArgsCtxKey struct{}
+
+func (t *queryOptionsTracer) shouldTrace(ctx context.Context, sql string) bool {
+	if queryTracingDisabled(ctx) {
+		return false
+	}
+
+	for _, prefix := range t.opts.DeniedStatementPrefixes {
+		if strings.HasPrefix(sql, prefix) {
+			return false
+		}
+	}
+
+	if len(t.opts.AllowedStatementPrefixes) > 0 {
+		allowed := false
+		for _, prefix := range t.opts.AllowedStatementPrefixes {
+			if strings.HasPrefix(sql, prefix) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+
+	if t.opts.QuerySamplingRatio > 0 && t.opts.QuerySamplingRatio < 1 {
+		return rand.Float64() < t.opts.QuerySamplingRatio
+	}
+
+	return true
+}
+
+func (t *queryOptionsTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if !t.shouldTrace(ctx, data.SQL) {
+		return context.WithValue(ctx, traceSuppressedCtxKey{}, true)
+	}
+
+	if t.opts.CaptureQueryArgs {
+		ctx = context.WithValue(ctx, queryArgsCtxKey{}, data.Args)
+	}
+
+	return ctx
+}
+
+func (t *queryOptionsTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	// No span was ever created for this query (the OTEL tracer skipped it
+	// via suppressibleTracer), so there's nothing to attach attributes to.
+	if traceSuppressed(ctx) {
+		return
+	}
+
+	span := trace.SpanFromContext(ctx)
+
+	if args, ok := ctx.Value(queryArgsCtxKey{}).([]any); ok {
+		span.SetAttributes(attribute.StringSlice("db.query.args", redactedArgs(args)))
+	}
+
+	if t.opts.CaptureRowsAffected {
+		span.SetAttributes(attribute.Int64("db.response.rows_affected", data.CommandTag.RowsAffected()))
+	}
+}
+
+// redactedArgs renders query args for span attribution, redacting any
+// map-shaped argument since SpiceDB passes caveat context that way and it
+// may contain caller-supplied sensitive values.
+func redactedArgs(args []any) []string {
+	rendered := make([]string, len(args))
+	for i, arg := range args {
+		if _, isMap := arg.(map[string]any); isMap {
+			rendered[i] = "<redacted>"
+			continue
+		}
+		rendered[i] = fmt.Sprintf("%v", arg)
+	}
+	return rendered
+}
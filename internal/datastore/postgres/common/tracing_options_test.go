@@ -0,0 +1,129 @@
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldTraceDeniedPrefixWins(t *testing.T) {
+	tracer := &queryOptionsTracer{opts: TracingOptions{
+		AllowedStatementPrefixes: []string{"SELECT"},
+		DeniedStatementPrefixes:  []string{"SELECT * FROM relation_tuple"},
+	}}
+
+	require.False(t, tracer.shouldTrace(context.Background(), "SELECT * FROM relation_tuple WHERE ..."))
+	require.True(t, tracer.shouldTrace(context.Background(), "SELECT * FROM namespace_config"))
+}
+
+func TestShouldTraceAllowList(t *testing.T) {
+	tracer := &queryOptionsTracer{opts: TracingOptions{
+		AllowedStatementPrefixes: []string{"INSERT INTO relation_tuple"},
+	}}
+
+	require.True(t, tracer.shouldTrace(context.Background(), "INSERT INTO relation_tuple VALUES (...)"))
+	require.False(t, tracer.shouldTrace(context.Background(), "SELECT * FROM namespace_config"))
+}
+
+func TestShouldTraceRespectsWithoutQueryTracing(t *testing.T) {
+	tracer := &queryOptionsTracer{}
+	ctx := WithoutQueryTracing(context.Background())
+
+	require.False(t, tracer.shouldTrace(ctx, "SELECT 1"))
+}
+
+func TestShouldTraceSamplingRatioBoundaries(t *testing.T) {
+	zeroRatio := &queryOptionsTracer{opts: TracingOptions{QuerySamplingRatio: 0}}
+	require.True(t, zeroRatio.shouldTrace(context.Background(), "SELECT 1"), "zero ratio means sample everything")
+
+	alwaysOn := &queryOptionsTracer{opts: TracingOptions{QuerySamplingRatio: 1}}
+	require.True(t, alwaysOn.shouldTrace(context.Background(), "SELECT 1"))
+}
+
+// spyTracer records whether pgx invoked it at all.
+type spyTracer struct {
+	queryStarted bool
+	queryEnded   bool
+}
+
+func (s *spyTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	s.queryStarted = true
+	return ctx
+}
+
+func (s *spyTracer) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {
+	s.queryEnded = true
+}
+
+func TestComposedTracerRunsEveryTracerRegardlessOfFilterDecision(t *testing.T) {
+	filtering := NewQueryOptionsTracer(TracingOptions{DeniedStatementPrefixes: []string{"SELECT"}})
+	spy := &spyTracer{}
+	composed := &ComposedTracer{Tracers: []pgx.QueryTracer{filtering, spy}}
+
+	ctx := composed.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT * FROM relation_tuple"})
+	composed.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+
+	require.True(t, spy.queryStarted, "a filtered query must still reach tracers other than the OTEL one, e.g. the logger and execution-time histogram")
+	require.True(t, spy.queryEnded)
+}
+
+func TestComposedTracerPropagatesAllowedQueries(t *testing.T) {
+	filtering := NewQueryOptionsTracer(TracingOptions{})
+	spy := &spyTracer{}
+	composed := &ComposedTracer{Tracers: []pgx.QueryTracer{filtering, spy}}
+
+	ctx := composed.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	composed.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+
+	require.True(t, spy.queryStarted)
+	require.True(t, spy.queryEnded)
+}
+
+func TestSuppressibleTracerSkipsWrappedTracerWhenFiltered(t *testing.T) {
+	filtering := NewQueryOptionsTracer(TracingOptions{DeniedStatementPrefixes: []string{"SELECT"}})
+	spy := &spyTracer{}
+	wrapped := &suppressibleTracer{tracer: spy}
+	composed := &ComposedTracer{Tracers: []pgx.QueryTracer{filtering, wrapped}}
+
+	ctx := composed.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT * FROM relation_tuple"})
+	composed.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+
+	require.False(t, spy.queryStarted, "a tracer wrapped in suppressibleTracer must not see a query queryOptionsTracer filtered out")
+	require.False(t, spy.queryEnded)
+}
+
+func TestSuppressibleTracerRunsWrappedTracerWhenAllowed(t *testing.T) {
+	filtering := NewQueryOptionsTracer(TracingOptions{})
+	spy := &spyTracer{}
+	wrapped := &suppressibleTracer{tracer: spy}
+	composed := &ComposedTracer{Tracers: []pgx.QueryTracer{filtering, wrapped}}
+
+	ctx := composed.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	composed.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+
+	require.True(t, spy.queryStarted)
+	require.True(t, spy.queryEnded)
+}
+
+func TestQueryOptionsTracerCapturesArgsAcrossStartAndEnd(t *testing.T) {
+	tracer := &queryOptionsTracer{opts: TracingOptions{CaptureQueryArgs: true}}
+
+	ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{
+		SQL:  "INSERT INTO relation_tuple (...) VALUES (...)",
+		Args: []any{"document", map[string]any{"secret": "value"}},
+	})
+
+	args, ok := ctx.Value(queryArgsCtxKey{}).([]any)
+	require.True(t, ok)
+	require.Equal(t, []any{"document", map[string]any{"secret": "value"}}, args)
+
+	// Must not panic even without a real span in context.
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+}
+
+func TestRedactedArgsRedactsMaps(t *testing.T) {
+	rendered := redactedArgs([]any{"doc", 42, map[string]any{"secret": "value"}})
+	require.Equal(t, []string{"doc", "42", "<redacted>"}, rendered)
+}